@@ -0,0 +1,142 @@
+// Package httpwl provides net/http middleware that gates requests
+// through a whitelist.ACL, extracting the client IP from the
+// connection's RemoteAddr or, for requests arriving through a trusted
+// proxy, from the X-Forwarded-For or Forwarded header.
+package httpwl
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/metopa/whitelist"
+)
+
+// Option configures optional behaviour of Middleware.
+type Option func(*options)
+
+type options struct {
+	trustForwardedFor bool
+	trustForwarded    bool
+	trustedProxies    whitelist.NetACL
+}
+
+// TrustXFF makes Middleware honour the X-Forwarded-For header's
+// left-most address as the client IP, but only when the immediate
+// peer (RemoteAddr) is permitted by trustedProxies. Without this
+// option, X-Forwarded-For is ignored and RemoteAddr is always used.
+func TrustXFF(trustedProxies whitelist.NetACL) Option {
+	return func(o *options) {
+		o.trustForwardedFor = true
+		o.trustedProxies = trustedProxies
+	}
+}
+
+// TrustForwarded is like TrustXFF but honours the standard Forwarded
+// header (RFC 7239) instead of X-Forwarded-For.
+func TrustForwarded(trustedProxies whitelist.NetACL) Option {
+	return func(o *options) {
+		o.trustForwarded = true
+		o.trustedProxies = trustedProxies
+	}
+}
+
+// Middleware returns a net/http middleware that checks the client IP
+// against acl and either forwards the request to the wrapped handler
+// or invokes denied. If denied is nil, a plain 403 Forbidden response
+// is written instead.
+func Middleware(acl whitelist.ACL, denied http.Handler, opts ...Option) func(http.Handler) http.Handler {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if denied == nil {
+		denied = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, o)
+			if ip == nil || !acl.Permitted(ip) {
+				denied.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP resolves the IP that should be checked against the ACL:
+// the immediate peer, unless it is a trusted proxy and a forwarding
+// header configured via Option says otherwise.
+func clientIP(r *http.Request, o *options) net.IP {
+	peer := peerIP(r)
+	if peer == nil {
+		return nil
+	}
+
+	if o.trustedProxies == nil || !o.trustedProxies.Permitted(peer) {
+		return peer
+	}
+
+	if o.trustForwarded {
+		if ip := forwardedFor(r.Header.Get("Forwarded")); ip != nil {
+			return ip
+		}
+	} else if o.trustForwardedFor {
+		if ip := xForwardedFor(r.Header.Get("X-Forwarded-For")); ip != nil {
+			return ip
+		}
+	}
+
+	return peer
+}
+
+func peerIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// xForwardedFor returns the left-most (originating client) address in
+// a comma-separated X-Forwarded-For header.
+func xForwardedFor(header string) net.IP {
+	if header == "" {
+		return nil
+	}
+	first := strings.TrimSpace(strings.SplitN(header, ",", 2)[0])
+	return net.ParseIP(first)
+}
+
+// forwardedFor returns the address from the "for=" parameter of the
+// first element of a Forwarded header (RFC 7239).
+func forwardedFor(header string) net.IP {
+	if header == "" {
+		return nil
+	}
+	first := strings.SplitN(header, ",", 2)[0]
+	for _, field := range strings.Split(first, ";") {
+		field = strings.TrimSpace(field)
+		name, value, found := strings.Cut(field, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "for") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		// SplitHostPort must see the bracketed form to parse a
+		// "[v6]:port" token correctly; only strip brackets by hand
+		// for the portless "[v6]" case it rejects.
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		} else {
+			value = strings.TrimPrefix(value, "[")
+			value = strings.TrimSuffix(value, "]")
+		}
+		return net.ParseIP(value)
+	}
+	return nil
+}
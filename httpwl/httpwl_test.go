@@ -0,0 +1,100 @@
+package httpwl
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metopa/whitelist"
+)
+
+func TestMiddlewarePermitsByRemoteAddr(t *testing.T) {
+	acl := whitelist.NewBasic()
+	acl.Add(net.ParseIP("192.0.2.1"))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Middleware(acl, nil)(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	req.RemoteAddr = "192.0.2.2:12345"
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rec.Code)
+	}
+}
+
+func TestMiddlewareTrustsXFFFromTrustedProxy(t *testing.T) {
+	acl := whitelist.NewBasic()
+	acl.Add(net.ParseIP("203.0.113.7"))
+
+	trustedProxies := whitelist.NewBasicNet()
+	_, proxyNet, _ := net.ParseCIDR("192.0.2.0/24")
+	trustedProxies.Add(proxyNet)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Middleware(acl, nil, TrustXFF(trustedProxies))(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 192.0.2.1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestForwardedForParsesBracketedIPv6WithPort(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{`for="[2001:db8::1]"`, "2001:db8::1"},
+		{`for="[2001:db8::1]:443"`, "2001:db8::1"},
+		{`for=192.0.2.1`, "192.0.2.1"},
+		{`for="192.0.2.1:443"`, "192.0.2.1"},
+	}
+
+	for _, c := range cases {
+		got := forwardedFor(c.header)
+		if got == nil || !got.Equal(net.ParseIP(c.want)) {
+			t.Fatalf("forwardedFor(%q) = %v, want %s", c.header, got, c.want)
+		}
+	}
+}
+
+func TestMiddlewareIgnoresXFFFromUntrustedPeer(t *testing.T) {
+	acl := whitelist.NewBasic()
+	acl.Add(net.ParseIP("203.0.113.7"))
+
+	trustedProxies := whitelist.NewBasicNet()
+	_, proxyNet, _ := net.ParseCIDR("192.0.2.0/24")
+	trustedProxies.Add(proxyNet)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Middleware(acl, nil, TrustXFF(trustedProxies))(next)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 since the untrusted peer's header must be ignored, got %d", rec.Code)
+	}
+}
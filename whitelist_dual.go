@@ -5,6 +5,8 @@ package whitelist
 
 import (
 	"net"
+	"sync"
+	"time"
 )
 
 const (
@@ -40,6 +42,9 @@ type BasicDual struct {
 	Addresses HostACL `json:"addresses"`
 	Networks  NetACL  `json:"networks"`
 	launchPolicy int  `json:"-"`
+	hub       eventHub
+	addrLock  sync.RWMutex
+	addrs     map[string]net.IP
 }
 
 // Permitted returns true if the IP has been whitelisted.
@@ -61,6 +66,15 @@ func (wl *BasicDual) Permitted(ip net.IP) bool {
 // AddAddress whitelists an IP.
 func (wl *BasicDual) AddAddress(ip net.IP) {
 	wl.Addresses.Add(ip)
+
+	wl.addrLock.Lock()
+	if wl.addrs == nil {
+		wl.addrs = make(map[string]net.IP)
+	}
+	wl.addrs[ip.String()] = ip
+	wl.addrLock.Unlock()
+
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
 }
 
 // BUG(kyle): overlapping networks aren't detected.
@@ -69,16 +83,50 @@ func (wl *BasicDual) AddAddress(ip net.IP) {
 // networks won't be detected.
 func (wl *BasicDual) AddNetwork(n *net.IPNet) {
 	wl.Networks.Add(n)
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
 }
 
 // RemoveAddress clears the IP from the whitelist.
 func (wl *BasicDual) RemoveAddress(ip net.IP) {
 	wl.Addresses.Remove(ip)
+
+	wl.addrLock.Lock()
+	delete(wl.addrs, ip.String())
+	wl.addrLock.Unlock()
+
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
 }
 
 // RemoveNetwork removes a network from the whitelist.
 func (wl *BasicDual) RemoveNetwork(n *net.IPNet) {
 	wl.Networks.Remove(n)
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// Subscribe registers for a live feed of Add/Remove events on this
+// whitelist.
+func (wl *BasicDual) Subscribe() (<-chan Event, CancelFunc) {
+	return wl.hub.subscribe()
+}
+
+// Snapshot returns an OpAdd Event for every address and network
+// currently in the whitelist, letting Replay rebuild this ACL's
+// state for a new subscriber. Addresses are tracked by BasicDual
+// itself, since the underlying HostACL (e.g. Basic) doesn't expose
+// its contents; Networks is asked directly if it supports
+// snapshotting.
+func (wl *BasicDual) Snapshot() []Event {
+	wl.addrLock.RLock()
+	events := make([]Event, 0, len(wl.addrs))
+	for _, ip := range wl.addrs {
+		events = append(events, Event{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
+	}
+	wl.addrLock.RUnlock()
+
+	if sn, ok := wl.Networks.(Snapshotter); ok {
+		events = append(events, sn.Snapshot()...)
+	}
+	return events
 }
 
 // NewBasicNet constructs a new basic dual whitelist.
@@ -0,0 +1,251 @@
+package whitelist
+
+// This file adds a pub/sub mechanism for ACL mutations, letting a
+// sidecar process (an iptables/nftables writer, a metrics exporter,
+// an audit log) follow live changes instead of polling. It mirrors
+// the "diff instead of full filter" approach Tailscale's
+// LocalBackend.updateFilter takes: subscribers see a stream of Events
+// rather than having to re-read the whole ACL after every change.
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Op is the kind of mutation an Event records.
+type Op int
+
+const (
+	// OpAdd records an entry being added to an ACL.
+	OpAdd Op = iota
+	// OpRemove records an entry being dropped from an ACL.
+	OpRemove
+)
+
+// Kind distinguishes whether an Event concerns a single address or a
+// network.
+type Kind int
+
+const (
+	// KindAddr marks an Event whose IP field is the whole story.
+	KindAddr Kind = iota
+	// KindNet marks an Event whose Net field is the whole story.
+	KindNet
+)
+
+// Event describes a single ACL mutation. Action records whether the
+// entry being added or removed is an accept or a deny rule; for ACLs
+// that don't distinguish the two (NetACL, DualACL) it is always
+// Accept.
+type Event struct {
+	Op     Op
+	Kind   Kind
+	Action Action
+	IP     net.IP
+	Net    *net.IPNet
+	Time   time.Time
+}
+
+// eventWire is the newline-delimited JSON shape Event (de)serialises
+// to: networks and addresses are written as human-readable strings,
+// like BasicNet and PortRule already do, rather than via net.IPNet's
+// default (base64-mask) struct marshalling.
+type eventWire struct {
+	Op     string    `json:"op"`
+	Kind   string    `json:"kind"`
+	Action string    `json:"action"`
+	IP     string    `json:"ip,omitempty"`
+	Net    string    `json:"net,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// MarshalJSON implements the json.Marshaler interface for Event.
+func (ev Event) MarshalJSON() ([]byte, error) {
+	w := eventWire{Time: ev.Time}
+
+	switch ev.Op {
+	case OpAdd:
+		w.Op = "add"
+	case OpRemove:
+		w.Op = "remove"
+	default:
+		return nil, errors.New("whitelist.Event: unknown op")
+	}
+
+	switch ev.Action {
+	case Accept:
+		w.Action = "accept"
+	case Deny:
+		w.Action = "deny"
+	default:
+		return nil, errors.New("whitelist.Event: unknown action")
+	}
+
+	switch ev.Kind {
+	case KindAddr:
+		w.Kind = "addr"
+		w.IP = ev.IP.String()
+	case KindNet:
+		w.Kind = "net"
+		w.Net = ev.Net.String()
+	default:
+		return nil, errors.New("whitelist.Event: unknown kind")
+	}
+
+	return json.Marshal(w)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for Event.
+func (ev *Event) UnmarshalJSON(in []byte) error {
+	var w eventWire
+	if err := json.Unmarshal(in, &w); err != nil {
+		return errors.New("whitelist.Event: " + err.Error())
+	}
+
+	switch w.Op {
+	case "add":
+		ev.Op = OpAdd
+	case "remove":
+		ev.Op = OpRemove
+	default:
+		return errors.New("whitelist.Event: unknown op " + w.Op)
+	}
+
+	switch w.Action {
+	case "accept":
+		ev.Action = Accept
+	case "deny":
+		ev.Action = Deny
+	default:
+		return errors.New("whitelist.Event: unknown action " + w.Action)
+	}
+
+	switch w.Kind {
+	case "addr":
+		ip := net.ParseIP(w.IP)
+		if ip == nil {
+			return errors.New("whitelist.Event: invalid IP address " + w.IP)
+		}
+		ev.Kind = KindAddr
+		ev.IP = ip
+	case "net":
+		_, n, err := net.ParseCIDR(w.Net)
+		if err != nil {
+			return errors.New("whitelist.Event: invalid IP network " + w.Net)
+		}
+		ev.Kind = KindNet
+		ev.Net = n
+	default:
+		return errors.New("whitelist.Event: unknown kind " + w.Kind)
+	}
+
+	ev.Time = w.Time
+	return nil
+}
+
+// CancelFunc unregisters a subscription created by Subscribe. It is
+// safe to call more than once.
+type CancelFunc func()
+
+// EventSource is implemented by ACLs that can notify subscribers of
+// their own mutations.
+type EventSource interface {
+	// Subscribe registers for a live feed of this ACL's mutations.
+	// The returned channel is closed once CancelFunc is called.
+	Subscribe() (<-chan Event, CancelFunc)
+}
+
+// Snapshotter is implemented by ACLs that can replay their entire
+// current state as a sequence of Events, e.g. for Replay.
+type Snapshotter interface {
+	Snapshot() []Event
+}
+
+// eventHub is an embeddable fan-out point for Events. Mutating methods
+// call publish; subscribers that aren't keeping up have events dropped
+// rather than blocking the mutation.
+type eventHub struct {
+	lock sync.Mutex
+	subs map[int]chan Event
+	next int
+}
+
+// subscribe registers a new subscriber and returns its channel and a
+// CancelFunc that unregisters it.
+func (h *eventHub) subscribe() (<-chan Event, CancelFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.subs == nil {
+		h.subs = make(map[int]chan Event)
+	}
+	id := h.next
+	h.next++
+	ch := make(chan Event, 16)
+	h.subs[id] = ch
+
+	return ch, func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		if c, ok := h.subs[id]; ok {
+			delete(h.subs, id)
+			close(c)
+		}
+	}
+}
+
+// publish fans ev out to every current subscriber without blocking;
+// a subscriber whose buffer is full misses the event.
+func (h *eventHub) publish(ev Event) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Replay writes acl's current state to w as newline-delimited JSON
+// Events (all with Op == OpAdd) so that a fresh subscriber can rebuild
+// the ACL's state before tailing live changes from Subscribe. It
+// returns an error if acl doesn't implement Snapshotter. DecodeEvents
+// reads the stream back.
+func Replay(w io.Writer, acl ACL) error {
+	sn, ok := acl.(Snapshotter)
+	if !ok {
+		return errors.New("whitelist.Replay: ACL does not support snapshotting")
+	}
+
+	enc := json.NewEncoder(w)
+	for _, ev := range sn.Snapshot() {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeEvents reads a newline-delimited JSON stream of Events as
+// written by Replay (or by encoding live Subscribe events), returning
+// them in order. It's the decode side of Replay's wire format.
+func DecodeEvents(r io.Reader) ([]Event, error) {
+	dec := json.NewDecoder(r)
+	var events []Event
+	for {
+		var ev Event
+		err := dec.Decode(&ev)
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+}
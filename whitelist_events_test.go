@@ -0,0 +1,191 @@
+package whitelist
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestBasicNetSubscribe(t *testing.T) {
+	wl := NewBasicNet()
+	ch, cancel := wl.Subscribe()
+	defer cancel()
+
+	_, n, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(n)
+
+	select {
+	case ev := <-ch:
+		if ev.Op != OpAdd || ev.Kind != KindNet || ev.Net.String() != n.String() {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	default:
+		t.Fatal("expected an event after Add")
+	}
+
+	wl.Remove(n)
+	select {
+	case ev := <-ch:
+		if ev.Op != OpRemove || ev.Kind != KindNet {
+			t.Fatalf("unexpected event: %+v", ev)
+		}
+	default:
+		t.Fatal("expected an event after Remove")
+	}
+}
+
+func TestBasicNetSubscribeCancel(t *testing.T) {
+	wl := NewBasicNet()
+	ch, cancel := wl.Subscribe()
+	cancel()
+
+	_, n, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(n)
+
+	if _, ok := <-ch; ok {
+		t.Fatal("expected channel to be closed after cancel")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	_, n, err := net.ParseCIDR("192.168.0.0/16")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(n)
+
+	var buf bytes.Buffer
+	if err := Replay(&buf, wl); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("expected Replay to write at least one event")
+	}
+}
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	_, n, err := net.ParseCIDR("10.1.2.0/24")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	cases := []Event{
+		{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: net.ParseIP("192.168.1.1")},
+		{Op: OpRemove, Kind: KindNet, Action: Deny, Net: n},
+	}
+
+	for _, want := range cases {
+		out, err := json.Marshal(want)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if bytes.Contains(out, []byte("Mask")) {
+			t.Fatalf("expected no raw net.IPNet fields in wire format, got %s", out)
+		}
+
+		var got Event
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if got.Op != want.Op || got.Kind != want.Kind || got.Action != want.Action {
+			t.Fatalf("round trip mismatch: got %+v, want %+v", got, want)
+		}
+		if want.Kind == KindAddr && !got.IP.Equal(want.IP) {
+			t.Fatalf("round trip IP mismatch: got %v, want %v", got.IP, want.IP)
+		}
+		if want.Kind == KindNet && got.Net.String() != want.Net.String() {
+			t.Fatalf("round trip network mismatch: got %v, want %v", got.Net, want.Net)
+		}
+	}
+}
+
+// TestReplayRebuildsBasicDual proves the gap the maintainer flagged is
+// closed: an address added via AddAddress must survive Replay, not
+// just networks added via AddNetwork.
+func TestReplayRebuildsBasicDual(t *testing.T) {
+	wl := NewBasicDual(LaunchPolicySequenced)
+	wl.AddAddress(net.ParseIP("1.2.3.4"))
+	_, n, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddNetwork(n)
+
+	var buf bytes.Buffer
+	if err := Replay(&buf, wl); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	events, err := DecodeEvents(&buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	rebuilt := NewBasicDual(LaunchPolicySequenced)
+	for _, ev := range events {
+		switch ev.Kind {
+		case KindAddr:
+			rebuilt.AddAddress(ev.IP)
+		case KindNet:
+			rebuilt.AddNetwork(ev.Net)
+		}
+	}
+
+	if !checkIPString(rebuilt, "1.2.3.4", t) {
+		t.Fatal("expected Replay to carry the individually-added address, not just networks")
+	}
+	if !checkIPString(rebuilt, "10.9.9.9", t) {
+		t.Fatal("expected Replay to carry the added network")
+	}
+}
+
+// TestReplayPreservesRuleAction proves a subscriber rebuilding a
+// RuleACL from Replay can tell a deny rule from an allow rule.
+func TestReplayPreservesRuleAction(t *testing.T) {
+	wl := NewAllowOnlyRule()
+	_, broad, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddNetwork(broad)
+	wl.AddDeny(net.ParseIP("10.0.0.5"))
+
+	var buf bytes.Buffer
+	if err := Replay(&buf, wl); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	events, err := DecodeEvents(&buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	rebuilt := NewAllowOnlyRule()
+	for _, ev := range events {
+		switch {
+		case ev.Kind == KindNet && ev.Action == Accept:
+			rebuilt.AddNetwork(ev.Net)
+		case ev.Kind == KindNet && ev.Action == Deny:
+			rebuilt.AddDenyNetwork(ev.Net)
+		case ev.Kind == KindAddr && ev.Action == Accept:
+			rebuilt.AddAddress(ev.IP)
+		case ev.Kind == KindAddr && ev.Action == Deny:
+			rebuilt.AddDeny(ev.IP)
+		}
+	}
+
+	if !checkIPString(rebuilt, "10.0.0.1", t) {
+		t.Fatal("expected broad allow to survive the round trip")
+	}
+	if checkIPString(rebuilt, "10.0.0.5", t) {
+		t.Fatal("expected the specific deny to survive the round trip as a deny, not be reconstructed as an allow")
+	}
+}
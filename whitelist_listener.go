@@ -0,0 +1,65 @@
+package whitelist
+
+// This file wraps a net.Listener so that connections from peers not
+// permitted by an ACL are transparently closed instead of being
+// handed to the caller's Accept loop.
+
+import (
+	"expvar"
+	"net"
+)
+
+// aclListener wraps a net.Listener, closing connections from peers
+// that the wrapped ACL does not permit.
+type aclListener struct {
+	net.Listener
+	acl      ACL
+	counters *expvar.Map
+}
+
+// NewListener wraps inner so that Accept only ever returns connections
+// from peers permitted by acl; connections from denied peers are
+// closed and Accept moves on to the next one. It works with any ACL
+// implementation, including BasicDual, TrieDual and the stub types.
+func NewListener(inner net.Listener, acl ACL) net.Listener {
+	return &aclListener{Listener: inner, acl: acl}
+}
+
+// NewListenerWithCounters is like NewListener but also increments
+// "accepted" and "denied" keys on counters for every connection seen,
+// so the effect of the whitelist can be monitored via expvar.
+func NewListenerWithCounters(inner net.Listener, acl ACL, counters *expvar.Map) net.Listener {
+	return &aclListener{Listener: inner, acl: acl, counters: counters}
+}
+
+// Accept returns the next connection from a permitted peer, closing
+// and skipping past any connections from denied peers.
+func (l *aclListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		var ip net.IP
+		if err == nil {
+			ip = net.ParseIP(host)
+		}
+
+		if ip == nil || !l.acl.Permitted(ip) {
+			l.incr("denied")
+			conn.Close()
+			continue
+		}
+
+		l.incr("accepted")
+		return conn, nil
+	}
+}
+
+func (l *aclListener) incr(key string) {
+	if l.counters != nil {
+		l.counters.Add(key, 1)
+	}
+}
@@ -0,0 +1,44 @@
+package whitelist
+
+import (
+	"expvar"
+	"net"
+	"testing"
+)
+
+func TestListenerAcceptCountsPermittedPeer(t *testing.T) {
+	acl := NewBasic()
+	acl.Add(net.ParseIP("127.0.0.1"))
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer inner.Close()
+
+	counters := &expvar.Map{}
+	wl := NewListenerWithCounters(inner, acl, counters)
+
+	done := make(chan error, 1)
+	go func() {
+		conn, err := wl.Accept()
+		if err == nil {
+			conn.Close()
+		}
+		done <- err
+	}()
+
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	conn.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if got := counters.Get("accepted").String(); got != "1" {
+		t.Fatalf("expected 1 accepted connection, got %s", got)
+	}
+}
@@ -0,0 +1,215 @@
+package whitelist
+
+// This file adds LocalACL, which gates which of a host's own network
+// interfaces may participate in whitelisting, following the approach
+// Nebula's LocalAllowList takes: IP/network rules are handled by an
+// existing DualACL, while interface-name rules are a separate ordered
+// list of regex patterns matched against net.Interface.Name.
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"regexp"
+	"sync"
+)
+
+// ifaceRule is a single interface-name rule: the first rule in the
+// list whose pattern matches an interface's name decides whether
+// addresses on that interface are permitted.
+type ifaceRule struct {
+	pattern *regexp.Regexp
+	allow   bool
+}
+
+// LocalACL composes an existing DualACL with an ordered list of
+// interface-name rules so that a host can gate which of its own NICs
+// participate in whitelisting. It must be initialised with
+// NewLocalACL or LoadLocalACLConfig.
+type LocalACL struct {
+	lock         sync.RWMutex
+	underlying   DualACL
+	rules        []ifaceRule
+	defaultAllow bool
+	ipToIface    map[string]string
+}
+
+// NewLocalACL constructs a LocalACL wrapping the given DualACL with no
+// interface rules and the given default. Call Refresh before first use
+// so that Permitted can resolve IPs to local interfaces.
+func NewLocalACL(underlying DualACL, defaultAllow bool) *LocalACL {
+	return &LocalACL{
+		underlying:   underlying,
+		defaultAllow: defaultAllow,
+		ipToIface:    make(map[string]string),
+	}
+}
+
+// AddInterfaceRule appends an interface-name rule to the end of the
+// ordered rule list.
+func (wl *LocalACL) AddInterfaceRule(pattern *regexp.Regexp, allow bool) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	wl.rules = append(wl.rules, ifaceRule{pattern: pattern, allow: allow})
+}
+
+// Refresh re-scans the host's network interfaces and their addresses,
+// rebuilding the IP-to-interface-name cache used by Permitted. It
+// should be called once at startup and again whenever the host's
+// links change.
+func (wl *LocalACL) Refresh() error {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string]string)
+	for i := range ifaces {
+		iface := &ifaces[i]
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ip, _, err := net.ParseCIDR(addr.String())
+			if err != nil {
+				continue
+			}
+			cache[ip.String()] = iface.Name
+		}
+	}
+
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	wl.ipToIface = cache
+	return nil
+}
+
+// interfaceAllowed reports whether ip, if it resolves to a local
+// interface, is permitted to participate by the interface-name rules.
+// The second return value is false if ip doesn't resolve to a local
+// interface, in which case the interface rules don't apply.
+func (wl *LocalACL) interfaceAllowed(ip net.IP) (allowed bool, isLocal bool) {
+	name, ok := wl.ipToIface[ip.String()]
+	if !ok {
+		return false, false
+	}
+
+	allowed = wl.defaultAllow
+	for i := range wl.rules {
+		if wl.rules[i].pattern.MatchString(name) {
+			allowed = wl.rules[i].allow
+			break
+		}
+	}
+	return allowed, true
+}
+
+// Permitted resolves ip to a local interface and applies the
+// interface-name rules first; if the interface is disallowed, the
+// address is denied outright. Otherwise (including when ip doesn't
+// resolve to a local interface at all) the underlying DualACL decides.
+func (wl *LocalACL) Permitted(ip net.IP) bool {
+	if !validIP(ip) {
+		return false
+	}
+
+	wl.lock.RLock()
+	allowed, isLocal := wl.interfaceAllowed(ip)
+	wl.lock.RUnlock()
+
+	if isLocal && !allowed {
+		return false
+	}
+
+	return wl.underlying.Permitted(ip)
+}
+
+// AddAddress whitelists an IP via the underlying DualACL.
+func (wl *LocalACL) AddAddress(ip net.IP) {
+	wl.underlying.AddAddress(ip)
+}
+
+// AddNetwork adds a network to the whitelist via the underlying DualACL.
+func (wl *LocalACL) AddNetwork(n *net.IPNet) {
+	wl.underlying.AddNetwork(n)
+}
+
+// RemoveAddress clears the IP from the underlying DualACL.
+func (wl *LocalACL) RemoveAddress(ip net.IP) {
+	wl.underlying.RemoveAddress(ip)
+}
+
+// RemoveNetwork removes a network from the underlying DualACL.
+func (wl *LocalACL) RemoveNetwork(n *net.IPNet) {
+	wl.underlying.RemoveNetwork(n)
+}
+
+// Subscribe registers for a live feed of the underlying DualACL's
+// Add/Remove events, if it supports them. If the underlying DualACL
+// doesn't implement EventSource, the returned channel is nil: it never
+// delivers an event and the returned CancelFunc does not close it.
+// Interface-name rule changes aren't events of their own; they take
+// effect immediately.
+func (wl *LocalACL) Subscribe() (<-chan Event, CancelFunc) {
+	if src, ok := wl.underlying.(EventSource); ok {
+		return src.Subscribe()
+	}
+	return nil, func() {}
+}
+
+// Snapshot delegates to the underlying DualACL, if it supports
+// snapshotting.
+func (wl *LocalACL) Snapshot() []Event {
+	if sn, ok := wl.underlying.(Snapshotter); ok {
+		return sn.Snapshot()
+	}
+	return nil
+}
+
+// localACLConfig is the JSON shape for LocalACL, matching Nebula's
+// local_allow_list: interfaces is an ordered list of single-entry
+// pattern-to-allow maps (order matters; a JSON object can't guarantee
+// it, so each entry gets its own map), and cidrs lists networks to add
+// to the underlying whitelist.
+type localACLConfig struct {
+	Interfaces   []map[string]bool `json:"interfaces"`
+	Cidrs        []string          `json:"cidrs"`
+	DefaultAllow bool              `json:"default_allow"`
+}
+
+// LoadLocalACLConfig parses a JSON LocalACL configuration and builds a
+// LocalACL wrapping the given DualACL, adding every entry in "cidrs"
+// to it. Refresh is called once before returning so Permitted can
+// resolve IPs to local interfaces immediately.
+func LoadLocalACLConfig(data []byte, underlying DualACL) (*LocalACL, error) {
+	var cfg localACLConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.New("whitelist.LocalACL: " + err.Error())
+	}
+
+	wl := NewLocalACL(underlying, cfg.DefaultAllow)
+	for _, m := range cfg.Interfaces {
+		for pattern, allow := range m {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, errors.New("whitelist.LocalACL: invalid interface pattern " + pattern)
+			}
+			wl.AddInterfaceRule(re, allow)
+		}
+	}
+
+	for _, c := range cfg.Cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, errors.New("whitelist.LocalACL: invalid cidr " + c)
+		}
+		wl.AddNetwork(n)
+	}
+
+	if err := wl.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return wl, nil
+}
@@ -0,0 +1,55 @@
+package whitelist
+
+import (
+	"net"
+	"regexp"
+	"testing"
+)
+
+func TestLocalACLInterfaceDeny(t *testing.T) {
+	wl := NewLocalACL(NewBasicDual(LaunchPolicySequenced), true)
+	if err := wl.Refresh(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddNetwork(mustCIDR(t, "127.0.0.0/8"))
+
+	loopback := net.ParseIP("127.0.0.1")
+	if !wl.Permitted(loopback) {
+		t.Fatal("expected loopback to be permitted with no interface rules")
+	}
+
+	wl.AddInterfaceRule(regexp.MustCompile("^lo"), false)
+	if wl.Permitted(loopback) {
+		t.Fatal("expected loopback to be denied once its interface is disallowed")
+	}
+}
+
+func TestLocalACLNonLocalIPSkipsInterfaceRules(t *testing.T) {
+	wl := NewLocalACL(NewBasicDual(LaunchPolicySequenced), true)
+	if err := wl.Refresh(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddInterfaceRule(regexp.MustCompile(".*"), false)
+	wl.AddNetwork(mustCIDR(t, "8.8.8.0/24"))
+
+	if !wl.Permitted(net.ParseIP("8.8.8.8")) {
+		t.Fatal("expected non-local address to be unaffected by interface rules")
+	}
+}
+
+func TestLoadLocalACLConfig(t *testing.T) {
+	cfg := `{
+		"interfaces": [{"^lo": false}],
+		"cidrs": ["127.0.0.0/8"],
+		"default_allow": true
+	}`
+
+	wl, err := LoadLocalACLConfig([]byte(cfg), NewBasicDual(LaunchPolicySequenced))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if wl.Permitted(net.ParseIP("127.0.0.1")) {
+		t.Fatal("expected configured interface deny rule to take effect")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 	"encoding/json"
 )
 
@@ -34,6 +35,7 @@ type BasicNet struct {
 	jsonFormat int
 	lock       sync.RWMutex
 	whitelist  []*net.IPNet
+	hub        eventHub
 }
 
 // Permitted returns true if the IP has been whitelisted.
@@ -63,8 +65,10 @@ func (wl *BasicNet) Add(n *net.IPNet) {
 	}
 
 	wl.lock.Lock()
-	defer wl.lock.Unlock()
 	wl.whitelist = append(wl.whitelist, n)
+	wl.lock.Unlock()
+
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
 }
 
 // Remove removes a network from the whitelist.
@@ -75,7 +79,6 @@ func (wl *BasicNet) Remove(n *net.IPNet) {
 
 	index := -1
 	wl.lock.Lock()
-	defer wl.lock.Unlock()
 	for i := range wl.whitelist {
 		if wl.whitelist[i].String() == n.String() {
 			index = i
@@ -84,10 +87,33 @@ func (wl *BasicNet) Remove(n *net.IPNet) {
 	}
 
 	if index == -1 {
+		wl.lock.Unlock()
 		return
 	}
 
 	wl.whitelist = append(wl.whitelist[:index], wl.whitelist[index + 1:]...)
+	wl.lock.Unlock()
+
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// Subscribe registers for a live feed of Add/Remove events on this
+// whitelist.
+func (wl *BasicNet) Subscribe() (<-chan Event, CancelFunc) {
+	return wl.hub.subscribe()
+}
+
+// Snapshot returns an OpAdd Event for every network currently in the
+// whitelist, letting Replay rebuild this ACL's state for a new
+// subscriber.
+func (wl *BasicNet) Snapshot() []Event {
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+	events := make([]Event, 0, len(wl.whitelist))
+	for _, n := range wl.whitelist {
+		events = append(events, Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+	}
+	return events
 }
 
 // NewBasicNet constructs a new basic network-based whitelist.
@@ -0,0 +1,296 @@
+package whitelist
+
+// This file adds a port- and protocol-aware ACL layer on top of the
+// existing NetACL types, modelled on Tailscale's filter.Match: rules
+// match on source/destination network, destination port range and IP
+// protocol. A TrieNet pre-filter over every rule's destination
+// networks (Tailscale's "dstIPs" trick) lets PermittedFlow short-
+// circuit packets to destinations no rule cares about before doing
+// the full rule scan.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PortRange is an inclusive range of destination ports, Lo <= Hi.
+type PortRange struct {
+	Lo uint16
+	Hi uint16
+}
+
+// Contains returns true if port falls within the range.
+func (r PortRange) Contains(port uint16) bool {
+	return port >= r.Lo && port <= r.Hi
+}
+
+func (r PortRange) String() string {
+	if r.Lo == r.Hi {
+		return strconv.Itoa(int(r.Lo))
+	}
+	return fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+}
+
+func parsePortRange(s string) (PortRange, error) {
+	s = strings.TrimSpace(s)
+	if lo, hi, found := strings.Cut(s, "-"); found {
+		loN, err := strconv.ParseUint(strings.TrimSpace(lo), 10, 16)
+		if err != nil {
+			return PortRange{}, err
+		}
+		hiN, err := strconv.ParseUint(strings.TrimSpace(hi), 10, 16)
+		if err != nil {
+			return PortRange{}, err
+		}
+		return PortRange{Lo: uint16(loN), Hi: uint16(hiN)}, nil
+	}
+
+	p, err := strconv.ParseUint(s, 10, 16)
+	if err != nil {
+		return PortRange{}, err
+	}
+	return PortRange{Lo: uint16(p), Hi: uint16(p)}, nil
+}
+
+// PortRule describes a single flow-matching rule: a flow is matched
+// if its source address is covered by SrcNets (any address if empty),
+// its destination address is covered by DstNets (any address if
+// empty), its destination port is covered by one of Ports (any port
+// if empty) and its protocol is one of Protos (any protocol if
+// empty).
+type PortRule struct {
+	SrcNets []*net.IPNet
+	DstNets []*net.IPNet
+	Ports   []PortRange
+	Protos  []uint8
+}
+
+func (r *PortRule) matchesSrc(ip net.IP) bool {
+	if len(r.SrcNets) == 0 {
+		return true
+	}
+	for _, n := range r.SrcNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PortRule) matchesDst(ip net.IP) bool {
+	if len(r.DstNets) == 0 {
+		return true
+	}
+	for _, n := range r.DstNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PortRule) matchesPort(port uint16) bool {
+	if len(r.Ports) == 0 {
+		return true
+	}
+	for _, pr := range r.Ports {
+		if pr.Contains(port) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PortRule) matchesProto(proto uint8) bool {
+	if len(r.Protos) == 0 {
+		return true
+	}
+	for _, p := range r.Protos {
+		if p == proto {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PortRule) matches(src, dst net.IP, dstPort uint16, proto uint8) bool {
+	return r.matchesSrc(src) && r.matchesDst(dst) && r.matchesPort(dstPort) && r.matchesProto(proto)
+}
+
+// jsonPortRule is the wire representation of a PortRule: networks and
+// port ranges are written as human-readable strings rather than
+// net.IPNet's raw byte fields.
+type jsonPortRule struct {
+	SrcNets []string `json:"srcNets,omitempty"`
+	DstNets []string `json:"dstNets,omitempty"`
+	Ports   []string `json:"ports,omitempty"`
+	Protos  []uint8  `json:"protos,omitempty"`
+}
+
+// MarshalJSON serialises a PortRule with its networks and port ranges
+// as human-readable strings.
+func (r *PortRule) MarshalJSON() ([]byte, error) {
+	jr := jsonPortRule{
+		Protos: r.Protos,
+	}
+	for _, n := range r.SrcNets {
+		jr.SrcNets = append(jr.SrcNets, n.String())
+	}
+	for _, n := range r.DstNets {
+		jr.DstNets = append(jr.DstNets, n.String())
+	}
+	for _, p := range r.Ports {
+		jr.Ports = append(jr.Ports, p.String())
+	}
+	return json.Marshal(jr)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for PortRule.
+func (r *PortRule) UnmarshalJSON(in []byte) error {
+	var jr jsonPortRule
+	if err := json.Unmarshal(in, &jr); err != nil {
+		return errors.New("whitelist.PortRule: " + err.Error())
+	}
+
+	r.SrcNets = nil
+	for _, s := range jr.SrcNets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return errors.New("whitelist.PortRule: invalid source network " + s)
+		}
+		r.SrcNets = append(r.SrcNets, n)
+	}
+
+	r.DstNets = nil
+	for _, s := range jr.DstNets {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return errors.New("whitelist.PortRule: invalid destination network " + s)
+		}
+		r.DstNets = append(r.DstNets, n)
+	}
+
+	r.Ports = nil
+	for _, s := range jr.Ports {
+		pr, err := parsePortRange(s)
+		if err != nil {
+			return errors.New("whitelist.PortRule: invalid port range " + s)
+		}
+		r.Ports = append(r.Ports, pr)
+	}
+
+	r.Protos = jr.Protos
+	return nil
+}
+
+// PortACL matches network flows against a set of PortRule entries.
+type PortACL interface {
+	// PermittedFlow returns true if a flow from src to dst:dstPort
+	// using the given IP protocol is permitted by the rule set.
+	PermittedFlow(src, dst net.IP, dstPort uint16, proto uint8) bool
+
+	// AddRule adds a rule to the set.
+	AddRule(PortRule)
+}
+
+// BasicPort implements PortACL using shared locks for concurrency. A
+// fast TrieNet pre-filter over every rule's destination networks lets
+// PermittedFlow reject flows to uninteresting destinations before
+// scanning the full rule set. It must be initialised with NewBasicPort.
+type BasicPort struct {
+	lock      sync.RWMutex
+	rules     []PortRule
+	dstFilter *TrieNet
+}
+
+// NewBasicPort constructs a new, empty port- and protocol-aware ACL.
+func NewBasicPort() *BasicPort {
+	return &BasicPort{
+		dstFilter: NewTrieNet(JsonFormatCompatibility),
+	}
+}
+
+var ipv4AnyNet, ipv6AnyNet *net.IPNet
+
+func init() {
+	_, ipv4AnyNet, _ = net.ParseCIDR("0.0.0.0/0")
+	_, ipv6AnyNet, _ = net.ParseCIDR("::/0")
+}
+
+// AddRule adds a rule to the set, indexing its destination networks
+// (or, if it has none, all addresses) in the destination pre-filter.
+func (wl *BasicPort) AddRule(r PortRule) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+
+	if len(r.DstNets) == 0 {
+		wl.dstFilter.Add(ipv4AnyNet)
+		wl.dstFilter.Add(ipv6AnyNet)
+	} else {
+		for _, n := range r.DstNets {
+			wl.dstFilter.Add(n)
+		}
+	}
+	wl.rules = append(wl.rules, r)
+}
+
+// PermittedFlow returns true if a flow from src to dst:dstPort using
+// the given IP protocol is permitted by any rule in the set.
+func (wl *BasicPort) PermittedFlow(src, dst net.IP, dstPort uint16, proto uint8) bool {
+	if !validIP(src) || !validIP(dst) {
+		return false
+	}
+
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+
+	if !wl.dstFilter.Permitted(dst) {
+		return false
+	}
+
+	for i := range wl.rules {
+		if wl.rules[i].matches(src, dst, dstPort, proto) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON serialises the rule set as a JSON array of rules.
+func (wl *BasicPort) MarshalJSON() ([]byte, error) {
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+	return json.Marshal(wl.rules)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for
+// BasicPort, rebuilding the destination pre-filter from the decoded
+// rules.
+func (wl *BasicPort) UnmarshalJSON(in []byte) error {
+	var rules []PortRule
+	if err := json.Unmarshal(in, &rules); err != nil {
+		return errors.New("whitelist.BasicPort: " + err.Error())
+	}
+
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	wl.rules = nil
+	wl.dstFilter = NewTrieNet(JsonFormatCompatibility)
+	for _, r := range rules {
+		if len(r.DstNets) == 0 {
+			wl.dstFilter.Add(ipv4AnyNet)
+			wl.dstFilter.Add(ipv6AnyNet)
+		} else {
+			for _, n := range r.DstNets {
+				wl.dstFilter.Add(n)
+			}
+		}
+		wl.rules = append(wl.rules, r)
+	}
+	return nil
+}
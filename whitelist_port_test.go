@@ -0,0 +1,83 @@
+package whitelist
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return n
+}
+
+func TestPortACLBasicMatch(t *testing.T) {
+	wl := NewBasicPort()
+	wl.AddRule(PortRule{
+		SrcNets: []*net.IPNet{mustCIDR(t, "192.168.0.0/16")},
+		DstNets: []*net.IPNet{mustCIDR(t, "10.0.0.0/8")},
+		Ports:   []PortRange{{Lo: 80, Hi: 80}, {Lo: 8000, Hi: 8999}},
+		Protos:  []uint8{6},
+	})
+
+	src := net.ParseIP("192.168.1.1")
+	dst := net.ParseIP("10.1.2.3")
+	if !wl.PermittedFlow(src, dst, 80, 6) {
+		t.Fatal("expected matching flow to be permitted")
+	}
+	if !wl.PermittedFlow(src, dst, 8500, 6) {
+		t.Fatal("expected port within range to be permitted")
+	}
+	if wl.PermittedFlow(src, dst, 443, 6) {
+		t.Fatal("expected non-matching port to be denied")
+	}
+	if wl.PermittedFlow(src, dst, 80, 17) {
+		t.Fatal("expected non-matching protocol to be denied")
+	}
+	if wl.PermittedFlow(src, net.ParseIP("172.16.0.1"), 80, 6) {
+		t.Fatal("expected destination pre-filter to reject unlisted destination")
+	}
+}
+
+func TestPortACLAnyMatch(t *testing.T) {
+	wl := NewBasicPort()
+	wl.AddRule(PortRule{
+		DstNets: []*net.IPNet{mustCIDR(t, "10.0.0.0/8")},
+	})
+
+	if !wl.PermittedFlow(net.ParseIP("1.2.3.4"), net.ParseIP("10.9.9.9"), 12345, 1) {
+		t.Fatal("expected rule with no src/port/proto restriction to match any flow to its destination")
+	}
+}
+
+func TestPortACLMarshal(t *testing.T) {
+	wl := NewBasicPort()
+	wl.AddRule(PortRule{
+		SrcNets: []*net.IPNet{mustCIDR(t, "192.168.0.0/16")},
+		DstNets: []*net.IPNet{mustCIDR(t, "10.0.0.0/8")},
+		Ports:   []PortRange{{Lo: 443, Hi: 443}},
+		Protos:  []uint8{6},
+	})
+
+	out, err := json.Marshal(wl)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	wlPrime := NewBasicPort()
+	if err := json.Unmarshal(out, wlPrime); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	src := net.ParseIP("192.168.1.1")
+	dst := net.ParseIP("10.1.2.3")
+	if !wlPrime.PermittedFlow(src, dst, 443, 6) {
+		t.Fatal("expected round-tripped rule set to still permit the matching flow")
+	}
+	if wlPrime.PermittedFlow(src, dst, 80, 6) {
+		t.Fatal("expected round-tripped rule set to still deny a non-matching port")
+	}
+}
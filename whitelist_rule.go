@@ -0,0 +1,229 @@
+package whitelist
+
+// This file contains a rule-based ACL that understands both accept and
+// deny entries plus a default action, following the model used by
+// go-maddr-filter's Filters type. It subsumes the allow-only NetACL
+// and DualACL implementations: those can be expressed as a RuleACL
+// with DefaultAction set to Deny and only accept entries added.
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// Action is the outcome a rule or a default policy produces for a
+// matching (or unmatched) IP.
+type Action int
+
+const (
+	// Accept permits the IP.
+	Accept Action = iota
+	// Deny rejects the IP.
+	Deny
+)
+
+// RuleACL is a DualACL that also supports explicit deny entries and a
+// configurable default action for IPs that match nothing.
+type RuleACL interface {
+	DualACL
+
+	// AddDeny takes an IP address and adds a deny rule for it.
+	AddDeny(net.IP)
+
+	// AddDenyNetwork takes an IP network and adds a deny rule for it.
+	AddDenyNetwork(*net.IPNet)
+
+	// SetDefaultAction sets the action applied when no rule matches.
+	SetDefaultAction(Action)
+}
+
+// rule is a single accept/deny entry. Exactly one of ip or network is
+// set, depending on whether AddAddress/AddDeny or AddNetwork/AddDenyNetwork
+// created it.
+type rule struct {
+	action  Action
+	ip      net.IP
+	network *net.IPNet
+}
+
+// specificity orders rules from least to most specific so that, e.g.,
+// a deny of 10.0.0.5/32 wins over an allow of 10.0.0.0/8. A bare
+// address is always the most specific possible match.
+func (r *rule) specificity() int {
+	if r.network == nil {
+		return 129
+	}
+	ones, _ := r.network.Mask.Size()
+	return ones
+}
+
+func (r *rule) matches(ip net.IP) bool {
+	if r.network != nil {
+		return r.network.Contains(ip)
+	}
+	return r.ip.Equal(ip)
+}
+
+// BasicRule implements RuleACL using shared locks for concurrency. It
+// must be initialised with one of the constructor functions. Like
+// BasicNet and BasicDual, rule matching is a linear scan and won't
+// scale to very large rule sets.
+type BasicRule struct {
+	lock          sync.RWMutex
+	defaultAction Action
+	rules         []rule
+	hub           eventHub
+}
+
+// Permitted returns true if the IP is allowed under the rule set: the
+// most specific matching rule decides, with later-inserted rules
+// winning ties, and the default action applying if nothing matches.
+func (wl *BasicRule) Permitted(ip net.IP) bool {
+	if !validIP(ip) {
+		return false
+	}
+
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+
+	matched := false
+	action := wl.defaultAction
+	best := -1
+	for i := range wl.rules {
+		r := &wl.rules[i]
+		if !r.matches(ip) {
+			continue
+		}
+		spec := r.specificity()
+		if !matched || spec >= best {
+			matched = true
+			best = spec
+			action = r.action
+		}
+	}
+
+	return action == Accept
+}
+
+// AddAddress adds an accept rule for the given IP address.
+func (wl *BasicRule) AddAddress(ip net.IP) {
+	if ip == nil {
+		return
+	}
+	wl.lock.Lock()
+	wl.rules = append(wl.rules, rule{action: Accept, ip: ip})
+	wl.lock.Unlock()
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
+}
+
+// AddNetwork adds an accept rule for the given IP network.
+func (wl *BasicRule) AddNetwork(n *net.IPNet) {
+	if n == nil {
+		return
+	}
+	wl.lock.Lock()
+	wl.rules = append(wl.rules, rule{action: Accept, network: n})
+	wl.lock.Unlock()
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// AddDeny adds a deny rule for the given IP address.
+func (wl *BasicRule) AddDeny(ip net.IP) {
+	if ip == nil {
+		return
+	}
+	wl.lock.Lock()
+	wl.rules = append(wl.rules, rule{action: Deny, ip: ip})
+	wl.lock.Unlock()
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindAddr, Action: Deny, IP: ip, Time: time.Now()})
+}
+
+// AddDenyNetwork adds a deny rule for the given IP network.
+func (wl *BasicRule) AddDenyNetwork(n *net.IPNet) {
+	if n == nil {
+		return
+	}
+	wl.lock.Lock()
+	wl.rules = append(wl.rules, rule{action: Deny, network: n})
+	wl.lock.Unlock()
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Deny, Net: n, Time: time.Now()})
+}
+
+// RemoveAddress drops the accept or deny rule for the given IP address.
+func (wl *BasicRule) RemoveAddress(ip net.IP) {
+	if action, ok := wl.removeMatching(func(r *rule) bool {
+		return r.network == nil && r.ip.Equal(ip)
+	}); ok {
+		wl.hub.publish(Event{Op: OpRemove, Kind: KindAddr, Action: action, IP: ip, Time: time.Now()})
+	}
+}
+
+// RemoveNetwork drops the accept or deny rule for the given IP network.
+func (wl *BasicRule) RemoveNetwork(n *net.IPNet) {
+	if action, ok := wl.removeMatching(func(r *rule) bool {
+		return r.network != nil && r.network.String() == n.String()
+	}); ok {
+		wl.hub.publish(Event{Op: OpRemove, Kind: KindNet, Action: action, Net: n, Time: time.Now()})
+	}
+}
+
+func (wl *BasicRule) removeMatching(match func(*rule) bool) (Action, bool) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	for i := range wl.rules {
+		if match(&wl.rules[i]) {
+			action := wl.rules[i].action
+			wl.rules = append(wl.rules[:i], wl.rules[i+1:]...)
+			return action, true
+		}
+	}
+	return 0, false
+}
+
+// Subscribe registers for a live feed of Add/Remove events on this
+// rule set.
+func (wl *BasicRule) Subscribe() (<-chan Event, CancelFunc) {
+	return wl.hub.subscribe()
+}
+
+// Snapshot returns an OpAdd Event for every rule currently in the set
+// (accept and deny alike), letting Replay rebuild this ACL's state
+// for a new subscriber.
+func (wl *BasicRule) Snapshot() []Event {
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+	events := make([]Event, 0, len(wl.rules))
+	for i := range wl.rules {
+		r := &wl.rules[i]
+		if r.network != nil {
+			events = append(events, Event{Op: OpAdd, Kind: KindNet, Action: r.action, Net: r.network, Time: time.Now()})
+		} else {
+			events = append(events, Event{Op: OpAdd, Kind: KindAddr, Action: r.action, IP: r.ip, Time: time.Now()})
+		}
+	}
+	return events
+}
+
+// SetDefaultAction sets the action applied when no rule matches.
+func (wl *BasicRule) SetDefaultAction(action Action) {
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	wl.defaultAction = action
+}
+
+// NewBasicRule constructs a new rule-based ACL with the given default
+// action and no rules.
+func NewBasicRule(defaultAction Action) *BasicRule {
+	return &BasicRule{
+		defaultAction: defaultAction,
+	}
+}
+
+// NewAllowOnlyRule constructs a rule-based ACL with DefaultAction set
+// to Deny. As long as only accept entries are added it behaves like the
+// allow-only NetACL/DualACL types; AddDeny and AddDenyNetwork still
+// work on it and carve exceptions out of the accepted entries.
+func NewAllowOnlyRule() *BasicRule {
+	return NewBasicRule(Deny)
+}
@@ -0,0 +1,72 @@
+package whitelist
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRuleDenyInsideAllow(t *testing.T) {
+	wl := NewAllowOnlyRule()
+	_, broad, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddNetwork(broad)
+	wl.AddDeny(net.ParseIP("10.0.0.5"))
+
+	if !checkIPString(wl, "10.0.0.1", t) {
+		t.Fatal("expected address covered only by the broad allow to be permitted")
+	}
+	if checkIPString(wl, "10.0.0.5", t) {
+		t.Fatal("expected the more specific deny to win over the broad allow")
+	}
+}
+
+func TestRuleDenyNetworkInsideAllow(t *testing.T) {
+	wl := NewAllowOnlyRule()
+	_, broad, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, narrow, err := net.ParseCIDR("10.0.0.0/24")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.AddNetwork(broad)
+	wl.AddDenyNetwork(narrow)
+
+	if checkIPString(wl, "10.0.0.1", t) {
+		t.Fatal("expected the more specific deny network to win over the broad allow")
+	}
+	if !checkIPString(wl, "10.1.0.1", t) {
+		t.Fatal("expected address outside the deny network to still be permitted")
+	}
+}
+
+func TestRuleDefaultAction(t *testing.T) {
+	allow := NewBasicRule(Accept)
+	if !checkIPString(allow, "8.8.8.8", t) {
+		t.Fatal("expected default-accept ACL to permit an unmatched address")
+	}
+
+	deny := NewBasicRule(Deny)
+	if checkIPString(deny, "8.8.8.8", t) {
+		t.Fatal("expected default-deny ACL to reject an unmatched address")
+	}
+	deny.SetDefaultAction(Accept)
+	if !checkIPString(deny, "8.8.8.8", t) {
+		t.Fatal("expected default action change to take effect")
+	}
+}
+
+func TestRuleRemove(t *testing.T) {
+	wl := NewAllowOnlyRule()
+	wl.AddAddress(net.ParseIP("192.168.1.1"))
+	if !checkIPString(wl, "192.168.1.1", t) {
+		t.Fatal("expected address to be permitted after AddAddress")
+	}
+	wl.RemoveAddress(net.ParseIP("192.168.1.1"))
+	if checkIPString(wl, "192.168.1.1", t) {
+		t.Fatal("expected address to be denied after RemoveAddress")
+	}
+}
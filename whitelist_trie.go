@@ -0,0 +1,281 @@
+package whitelist
+
+// This file contains a radix-trie backed variant of NetACL. Unlike
+// BasicNet, which does a linear scan over every stored network, TrieNet
+// walks a bitwise CIDR trie so that Permitted costs O(prefix length)
+// instead of O(number of networks). The approach mirrors the
+// cidr.Tree4/Tree6 structures used by Nebula: separate trees for IPv4
+// and IPv6, one node per bit, with an optional "present" marker at the
+// node reached after consuming a prefix's bits.
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// trieNode is a single bit position in a CIDR radix trie. count is the
+// number of whitelisted entries that terminate at the node reached
+// after walking a prefix's bits; children[0]/children[1] continue the
+// walk for the next bit. count is reference-counted rather than a
+// bare bool so that adding the same prefix twice (e.g. via two equal
+// Add calls) requires two Removes to actually clear it, matching
+// BasicNet's slice semantics where a duplicate entry isn't dropped by
+// a single Remove.
+type trieNode struct {
+	children [2]*trieNode
+	count    int
+}
+
+// walkBit returns the bit at position i (0 = most significant) of ip.
+func walkBit(ip net.IP, i int) int {
+	return int(ip[i/8]>>(7-uint(i%8))) & 1
+}
+
+// add increments the count of the node reached by following the first
+// ones bits of ip, creating intermediate nodes as needed.
+func (n *trieNode) add(ip net.IP, ones int) {
+	cur := n
+	for i := 0; i < ones; i++ {
+		bit := walkBit(ip, i)
+		if cur.children[bit] == nil {
+			cur.children[bit] = &trieNode{}
+		}
+		cur = cur.children[bit]
+	}
+	cur.count++
+}
+
+// contains returns true if any node along the first length bits of ip
+// has a positive count, i.e. ip falls inside a whitelisted prefix.
+func (n *trieNode) contains(ip net.IP, length int) bool {
+	cur := n
+	if cur.count > 0 {
+		return true
+	}
+	for i := 0; i < length; i++ {
+		cur = cur.children[walkBit(ip, i)]
+		if cur == nil {
+			return false
+		}
+		if cur.count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// remove decrements the count of the node reached by following the
+// first ones bits of ip and prunes any branch left with a zero count
+// and no children.
+func (n *trieNode) remove(ip net.IP, ones int) {
+	path := make([]*trieNode, 1, ones+1)
+	path[0] = n
+	cur := n
+	for i := 0; i < ones; i++ {
+		next := cur.children[walkBit(ip, i)]
+		if next == nil {
+			return
+		}
+		path = append(path, next)
+		cur = next
+	}
+	if cur.count == 0 {
+		return
+	}
+	cur.count--
+
+	for i := len(path) - 1; i > 0; i-- {
+		node := path[i]
+		if node.count > 0 || node.children[0] != nil || node.children[1] != nil {
+			break
+		}
+		path[i-1].children[walkBit(ip, i-1)] = nil
+	}
+}
+
+// TrieNet implements a network whitelist backed by separate IPv4 and
+// IPv6 radix tries, giving Permitted a cost proportional to the
+// address length rather than to the number of whitelisted networks.
+// It must be initialised with one of the constructor functions.
+type TrieNet struct {
+	jsonFormat int
+	lock       sync.RWMutex
+	v4root     trieNode
+	v6root     trieNode
+	whitelist  []*net.IPNet
+	hub        eventHub
+}
+
+// Permitted returns true if the IP falls inside any whitelisted
+// network, including ones nested inside a broader whitelisted network.
+func (wl *TrieNet) Permitted(ip net.IP) bool {
+	if !validIP(ip) {
+		return false
+	}
+
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+	if ip4 := ip.To4(); ip4 != nil {
+		return wl.v4root.contains(ip4, 32)
+	}
+	return wl.v6root.contains(ip.To16(), 128)
+}
+
+// Add adds a new network to the whitelist. Overlapping networks are
+// handled naturally: Permitted matches if any prefix along the path,
+// not just the most specific one, is whitelisted.
+func (wl *TrieNet) Add(n *net.IPNet) {
+	if n == nil {
+		return
+	}
+
+	ones, _ := n.Mask.Size()
+
+	wl.lock.Lock()
+	if ip4 := n.IP.To4(); ip4 != nil {
+		wl.v4root.add(ip4, ones)
+	} else {
+		wl.v6root.add(n.IP.To16(), ones)
+	}
+	wl.whitelist = append(wl.whitelist, n)
+	wl.lock.Unlock()
+
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// Remove removes a network from the whitelist.
+func (wl *TrieNet) Remove(n *net.IPNet) {
+	if n == nil {
+		return
+	}
+
+	wl.lock.Lock()
+
+	index := -1
+	for i := range wl.whitelist {
+		if wl.whitelist[i].String() == n.String() {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		wl.lock.Unlock()
+		return
+	}
+
+	ones, _ := n.Mask.Size()
+	if ip4 := n.IP.To4(); ip4 != nil {
+		wl.v4root.remove(ip4, ones)
+	} else {
+		wl.v6root.remove(n.IP.To16(), ones)
+	}
+	wl.whitelist = append(wl.whitelist[:index], wl.whitelist[index+1:]...)
+	wl.lock.Unlock()
+
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// Subscribe registers for a live feed of Add/Remove events on this
+// whitelist.
+func (wl *TrieNet) Subscribe() (<-chan Event, CancelFunc) {
+	return wl.hub.subscribe()
+}
+
+// Snapshot returns an OpAdd Event for every network currently in the
+// whitelist, letting Replay rebuild this ACL's state for a new
+// subscriber.
+func (wl *TrieNet) Snapshot() []Event {
+	wl.lock.RLock()
+	defer wl.lock.RUnlock()
+	events := make([]Event, 0, len(wl.whitelist))
+	for _, n := range wl.whitelist {
+		events = append(events, Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+	}
+	return events
+}
+
+// NewTrieNet constructs a new trie-based network whitelist using the
+// given JSON format.
+func NewTrieNet(jsonFormat int) *TrieNet {
+	return &TrieNet{
+		jsonFormat: jsonFormat,
+	}
+}
+
+// MarshalJSON serialises a network whitelist to a comma-separated
+// list of networks (compatibility format) or a JSON array of strings (new format).
+func (wl *TrieNet) MarshalJSON() ([]byte, error) {
+	var ss = make([]string, 0, len(wl.whitelist))
+	wl.lock.RLock()
+	for i := range wl.whitelist {
+		ss = append(ss, wl.whitelist[i].String())
+	}
+	wl.lock.RUnlock()
+	var out []byte
+	if wl.jsonFormat == JsonFormatCompatibility {
+		out = []byte(`"` + strings.Join(ss, ",") + `"`)
+	} else if wl.jsonFormat == JsonFormatNew {
+		if len(ss) > 0 {
+			out = []byte(`["` + strings.Join(ss, `","`) + `"]`)
+		} else {
+			out = []byte("[]")
+		}
+	} else {
+		return nil, errors.New("whitelist.TrieNet: unsupported JSON format")
+	}
+
+	return out, nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for
+// trie-backed network whitelists, taking a comma-separated string of
+// networks or a JSON array of strings.
+func (wl *TrieNet) UnmarshalJSON(in []byte) error {
+	newFormat := false
+	if in[0] == '[' && in[len(in)-1] == ']' {
+		newFormat = true
+	}
+
+	if !newFormat && (in[0] != '"' || in[len(in)-1] != '"') {
+		return errors.New("whitelist.TrieNet: invalid whitelist")
+	}
+
+	var nets []string
+	if !newFormat {
+		netString := strings.TrimSpace(string(in[1 : len(in)-1]))
+		nets = strings.Split(netString, ",")
+	} else {
+		if err := json.Unmarshal(in, &nets); err != nil {
+			return errors.New("whitelist.TrieNet: " + err.Error())
+		}
+	}
+
+	wl.lock.Lock()
+	defer wl.lock.Unlock()
+	wl.v4root = trieNode{}
+	wl.v6root = trieNode{}
+	wl.whitelist = make([]*net.IPNet, 0, len(nets))
+	for i := range nets {
+		addr := strings.TrimSpace(nets[i])
+		if addr == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(addr)
+		if err != nil {
+			wl.whitelist = nil
+			return errors.New("whitelist.TrieNet: invalid IP network " + addr)
+		}
+		ones, _ := n.Mask.Size()
+		if ip4 := n.IP.To4(); ip4 != nil {
+			wl.v4root.add(ip4, ones)
+		} else {
+			wl.v6root.add(n.IP.To16(), ones)
+		}
+		wl.whitelist = append(wl.whitelist, n)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package whitelist
+
+// This file pairs TrieNet with the address whitelist to give a
+// DualACL implementation backed by the radix trie rather than
+// BasicNet's linear scan.
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// TrieDual implements DualACL using a trie-backed network whitelist
+// for its Networks half instead of BasicNet's linear scan.
+type TrieDual struct {
+	Addresses    HostACL `json:"addresses"`
+	Networks     NetACL  `json:"networks"`
+	launchPolicy int     `json:"-"`
+	hub          eventHub
+	addrLock     sync.RWMutex
+	addrs        map[string]net.IP
+}
+
+// Permitted returns true if the IP has been whitelisted.
+func (wl *TrieDual) Permitted(ip net.IP) bool {
+	if wl.launchPolicy == LaunchPolicySequenced {
+		return wl.Addresses.Permitted(ip) || wl.Networks.Permitted(ip)
+	} else { //LaunchPolicyAsync
+		res := make(chan bool, 2)
+		go func() {
+			res <- wl.Addresses.Permitted(ip)
+		}()
+		go func() {
+			res <- wl.Networks.Permitted(ip)
+		}()
+		return <-res || <-res
+	}
+}
+
+// AddAddress whitelists an IP.
+func (wl *TrieDual) AddAddress(ip net.IP) {
+	wl.Addresses.Add(ip)
+
+	wl.addrLock.Lock()
+	if wl.addrs == nil {
+		wl.addrs = make(map[string]net.IP)
+	}
+	wl.addrs[ip.String()] = ip
+	wl.addrLock.Unlock()
+
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
+}
+
+// AddNetwork adds a new network to the whitelist.
+func (wl *TrieDual) AddNetwork(n *net.IPNet) {
+	wl.Networks.Add(n)
+	wl.hub.publish(Event{Op: OpAdd, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// RemoveAddress clears the IP from the whitelist.
+func (wl *TrieDual) RemoveAddress(ip net.IP) {
+	wl.Addresses.Remove(ip)
+
+	wl.addrLock.Lock()
+	delete(wl.addrs, ip.String())
+	wl.addrLock.Unlock()
+
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
+}
+
+// RemoveNetwork removes a network from the whitelist.
+func (wl *TrieDual) RemoveNetwork(n *net.IPNet) {
+	wl.Networks.Remove(n)
+	wl.hub.publish(Event{Op: OpRemove, Kind: KindNet, Action: Accept, Net: n, Time: time.Now()})
+}
+
+// Subscribe registers for a live feed of Add/Remove events on this
+// whitelist.
+func (wl *TrieDual) Subscribe() (<-chan Event, CancelFunc) {
+	return wl.hub.subscribe()
+}
+
+// Snapshot returns an OpAdd Event for every address and network
+// currently in the whitelist, letting Replay rebuild this ACL's
+// state for a new subscriber. Addresses are tracked by TrieDual
+// itself, since the underlying HostACL (e.g. Basic) doesn't expose
+// its contents; Networks is asked directly if it supports
+// snapshotting.
+func (wl *TrieDual) Snapshot() []Event {
+	wl.addrLock.RLock()
+	events := make([]Event, 0, len(wl.addrs))
+	for _, ip := range wl.addrs {
+		events = append(events, Event{Op: OpAdd, Kind: KindAddr, Action: Accept, IP: ip, Time: time.Now()})
+	}
+	wl.addrLock.RUnlock()
+
+	if sn, ok := wl.Networks.(Snapshotter); ok {
+		events = append(events, sn.Snapshot()...)
+	}
+	return events
+}
+
+// NewTrieDual constructs a new trie-backed dual whitelist.
+func NewTrieDual(launchPolicy int) *TrieDual {
+	return &TrieDual{
+		Addresses:    NewBasic(),
+		Networks:     NewTrieNet(JsonFormatCompatibility),
+		launchPolicy: launchPolicy,
+	}
+}
@@ -0,0 +1,132 @@
+package whitelist
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+)
+
+func TestMarshalCompatTrieNet(t *testing.T) {
+	tv := map[string]*TrieNet{
+		"test-a": NewTrieNet(JsonFormatCompatibility),
+		"test-b": NewTrieNet(JsonFormatCompatibility),
+	}
+
+	_, n, err := net.ParseCIDR("192.168.3.0/24")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tv["test-a"].Add(n)
+
+	_, n, err = net.ParseCIDR("192.168.7.0/24")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	tv["test-a"].Add(n)
+
+	out, err := json.Marshal(tv)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var tvPrime map[string]*TrieNet
+	err = json.Unmarshal(out, &tvPrime)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(tvPrime["test-a"].whitelist) != 2 {
+		t.Fatalf("Expected whitelist to have 2 addresses, but have %d", len(tvPrime["test-a"].whitelist))
+	}
+
+	if !checkIPString(tvPrime["test-a"], "192.168.3.1", t) || !checkIPString(tvPrime["test-a"], "192.168.7.255", t) {
+		t.Fatal("whitelist should have permitted address")
+	}
+
+	if checkIPString(tvPrime["test-b"], "192.168.3.1", t) {
+		t.Fatal("whitelist should have denied address")
+	}
+}
+
+func TestTrieNetOverlapping(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	_, broad, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, narrow, err := net.ParseCIDR("10.1.2.0/24")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(broad)
+	wl.Add(narrow)
+
+	if !checkIPString(wl, "10.1.2.3", t) {
+		t.Fatal("expected overlapping narrow network to be permitted")
+	}
+	if !checkIPString(wl, "10.9.9.9", t) {
+		t.Fatal("expected broad network to be permitted")
+	}
+
+	wl.Remove(narrow)
+	if !checkIPString(wl, "10.1.2.3", t) {
+		t.Fatal("expected broad network to still cover address after narrow removal")
+	}
+
+	wl.Remove(broad)
+	if checkIPString(wl, "10.1.2.3", t) {
+		t.Fatal("expected address to be denied once all covering networks are removed")
+	}
+}
+
+func TestTrieNetDuplicateAddSurvivesSingleRemove(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	_, n, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(n)
+	wl.Add(n)
+
+	wl.Remove(n)
+	if !checkIPString(wl, "10.9.9.9", t) {
+		t.Fatal("expected network to still be permitted after removing only one of two duplicate adds")
+	}
+	if len(wl.whitelist) != 1 {
+		t.Fatalf("expected whitelist to have 1 entry left, got %d", len(wl.whitelist))
+	}
+
+	wl.Remove(n)
+	if checkIPString(wl, "10.9.9.9", t) {
+		t.Fatal("expected network to be denied once the last duplicate is removed")
+	}
+}
+
+func TestTrieNetIPv6(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	_, n, err := net.ParseCIDR("2001:db8::/32")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wl.Add(n)
+
+	if !checkIPString(wl, "2001:db8::1", t) {
+		t.Fatal("expected IPv6 address to be permitted")
+	}
+	if checkIPString(wl, "2001:db9::1", t) {
+		t.Fatal("expected IPv6 address outside network to be denied")
+	}
+}
+
+func TestTrieNetAddRemoveNil(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	wl.Add(nil)
+	wl.Remove(nil)
+}
+
+func TestTrieNetFailPermitted(t *testing.T) {
+	wl := NewTrieNet(JsonFormatCompatibility)
+	var ip = []byte{0, 0}
+	if wl.Permitted(ip) {
+		t.Fatal("Expected failure checking invalid IP address.")
+	}
+}